@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/influxdata/influxdb/kv"
+)
+
+var (
+	schedulerItemsBucket = []byte("taskSchedulerItemsV1")
+	schedulerRunsBucket  = []byte("taskSchedulerRunsV1")
+)
+
+// kvStore is a SchedulerStore backed by the same kv.Store abstraction the
+// rest of influxdb's metadata uses (see tenant.Service), so that whatever
+// backend (bolt, etc.) the server is already running on is enough to
+// reconstruct the scheduler's cron cursors and in-flight runs after a
+// restart.
+type kvStore struct {
+	kv kv.Store
+}
+
+// NewKVStore returns a SchedulerStore persisted in store.
+func NewKVStore(store kv.Store) SchedulerStore {
+	return &kvStore{kv: store}
+}
+
+type storedItemRecord struct {
+	TaskID     ID
+	CronString string
+	Offset     time.Duration
+	Next       int64
+	Nonce      int
+	Priority   Priority
+
+	Quarantined      bool
+	QuarantinedAt    int64
+	QuarantinedUntil int64
+}
+
+type storedRunRecord struct {
+	TaskID    ID
+	RunID     ID
+	StartedAt int64
+}
+
+func (k *kvStore) UpsertScheduled(ctx context.Context, it StoredItem) error {
+	rec := storedItemRecord{
+		TaskID:     it.TaskID,
+		CronString: it.CronString,
+		Offset:     it.Offset,
+		Next:       it.Next.Unix(),
+		Nonce:      it.Nonce,
+		Priority:   it.Priority,
+
+		Quarantined:      it.Quarantined,
+		QuarantinedAt:    it.QuarantinedAt.Unix(),
+		QuarantinedUntil: it.QuarantinedUntil.Unix(),
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return k.kv.Update(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(schedulerItemsBucket)
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(it.TaskID.String()), b)
+	})
+}
+
+func (k *kvStore) DeleteScheduled(ctx context.Context, taskID ID) error {
+	return k.kv.Update(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(schedulerItemsBucket)
+		if err != nil {
+			return err
+		}
+		return bkt.Delete([]byte(taskID.String()))
+	})
+}
+
+func (k *kvStore) RecordRun(ctx context.Context, taskID, runID ID, startedAt time.Time) error {
+	rec := storedRunRecord{TaskID: taskID, RunID: runID, StartedAt: startedAt.Unix()}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return k.kv.Update(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(schedulerRunsBucket)
+		if err != nil {
+			return err
+		}
+		return bkt.Put(runKey(taskID, runID), b)
+	})
+}
+
+func (k *kvStore) CompleteRun(ctx context.Context, taskID, runID ID, _ error) error {
+	return k.kv.Update(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(schedulerRunsBucket)
+		if err != nil {
+			return err
+		}
+		return bkt.Delete(runKey(taskID, runID))
+	})
+}
+
+func (k *kvStore) LoadAll(ctx context.Context) ([]StoredItem, []StoredRun, error) {
+	var items []StoredItem
+	var runs []StoredRun
+	err := k.kv.View(ctx, func(tx kv.Tx) error {
+		ibkt, err := tx.Bucket(schedulerItemsBucket)
+		if err != nil {
+			return err
+		}
+		if err := walkBucket(ibkt, func(_, v []byte) error {
+			var rec storedItemRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			items = append(items, StoredItem{
+				TaskID:     rec.TaskID,
+				CronString: rec.CronString,
+				Offset:     rec.Offset,
+				Next:       time.Unix(rec.Next, 0),
+				Nonce:      rec.Nonce,
+				Priority:   rec.Priority,
+
+				Quarantined:      rec.Quarantined,
+				QuarantinedAt:    time.Unix(rec.QuarantinedAt, 0),
+				QuarantinedUntil: time.Unix(rec.QuarantinedUntil, 0),
+			})
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		rbkt, err := tx.Bucket(schedulerRunsBucket)
+		if err != nil {
+			return err
+		}
+		return walkBucket(rbkt, func(_, v []byte) error {
+			var rec storedRunRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			runs = append(runs, StoredRun{
+				TaskID:    rec.TaskID,
+				RunID:     rec.RunID,
+				StartedAt: time.Unix(rec.StartedAt, 0),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, runs, nil
+}
+
+// walkBucket calls visit for every key/value pair in bkt. kv.Bucket has no
+// ForEach; ranging over an entire bucket goes through ForwardCursor instead,
+// the same way the rest of this codebase's kv-backed stores do.
+func walkBucket(bkt kv.Bucket, visit func(k, v []byte) error) error {
+	cur, err := bkt.ForwardCursor(nil)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	for k, v := cur.Next(); k != nil; k, v = cur.Next() {
+		if err := visit(k, v); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func runKey(taskID, runID ID) []byte {
+	return []byte(taskID.String() + "/" + runID.String())
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/btree"
+)
+
+// ErrRunNotFound is returned by CancelRun when taskID/runID isn't currently
+// running.
+var ErrRunNotFound = errors.New("run not found")
+
+// ScheduledItem is a read-only snapshot of a single task's pending entry,
+// as returned by ListScheduled.
+type ScheduledItem struct {
+	TaskID   ID
+	Next     time.Time
+	Nonce    int
+	Priority Priority
+}
+
+// RunningItem is a read-only snapshot of a single in-flight run, as
+// returned by ListRunning.
+type RunningItem struct {
+	TaskID ID
+	RunID  ID
+}
+
+// ListScheduled returns a snapshot of every task currently waiting in a
+// priority bucket, across all buckets. It's the read side of the control
+// plane: ops tools and the UI can use it to see what the scheduler intends
+// to do next without reaching into its internals.
+func (s *TreeScheduler) ListScheduled() []ScheduledItem {
+	s.RLock()
+	defer s.RUnlock()
+	items := make([]ScheduledItem, 0, len(s.nextTime))
+	for p := range s.scheduled {
+		s.scheduled[p].Ascend(func(i btree.Item) bool {
+			it := i.(item)
+			items = append(items, ScheduledItem{
+				TaskID:   it.id,
+				Next:     time.Unix(it.next, 0),
+				Nonce:    it.nonce,
+				Priority: it.prio,
+			})
+			return true
+		})
+	}
+	return items
+}
+
+// ListRunning returns a snapshot of every run currently in flight.
+func (s *TreeScheduler) ListRunning() []RunningItem {
+	running := s.runningAll()
+	items := make([]RunningItem, 0, len(running))
+	for _, ri := range running {
+		items = append(items, RunningItem{TaskID: ri.taskID, RunID: ri.runID})
+	}
+	return items
+}
+
+// CancelRun cancels a single in-flight run by invoking the cancel func
+// stored alongside it in running. It returns ErrRunNotFound if taskID/runID
+// isn't currently running.
+func (s *TreeScheduler) CancelRun(taskID, runID ID) error {
+	ri, ok := s.runningGet(taskID, runID)
+	if !ok {
+		return ErrRunNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeOut)
+	defer cancel()
+	ri.cancel(ctx)
+	return nil
+}
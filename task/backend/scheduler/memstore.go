@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// inMemStore is a SchedulerStore that keeps everything in a map and drops it
+// on process exit. It's the default store used by tests and by callers who
+// haven't opted into durability with WithStore.
+type inMemStore struct {
+	mu        sync.Mutex
+	scheduled map[ID]StoredItem
+	running   map[ID]map[ID]StoredRun // taskID -> runID -> run
+}
+
+// NewInMemStore returns a SchedulerStore with no durability: everything it
+// holds is lost on process restart. Useful as the store for tests, or as an
+// explicit opt-out when a caller doesn't want WithStore's repopulation
+// behavior but still wants a non-nil store.
+func NewInMemStore() SchedulerStore {
+	return &inMemStore{
+		scheduled: make(map[ID]StoredItem),
+		running:   make(map[ID]map[ID]StoredRun),
+	}
+}
+
+func (m *inMemStore) UpsertScheduled(_ context.Context, it StoredItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduled[it.TaskID] = it
+	return nil
+}
+
+func (m *inMemStore) DeleteScheduled(_ context.Context, taskID ID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.scheduled, taskID)
+	return nil
+}
+
+func (m *inMemStore) RecordRun(_ context.Context, taskID, runID ID, startedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs, ok := m.running[taskID]
+	if !ok {
+		runs = make(map[ID]StoredRun)
+		m.running[taskID] = runs
+	}
+	runs[runID] = StoredRun{TaskID: taskID, RunID: runID, StartedAt: startedAt}
+	return nil
+}
+
+func (m *inMemStore) CompleteRun(_ context.Context, taskID, runID ID, _ error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs, ok := m.running[taskID]
+	if !ok {
+		return nil
+	}
+	delete(runs, runID)
+	if len(runs) == 0 {
+		delete(m.running, taskID)
+	}
+	return nil
+}
+
+func (m *inMemStore) LoadAll(_ context.Context) ([]StoredItem, []StoredRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := make([]StoredItem, 0, len(m.scheduled))
+	for _, it := range m.scheduled {
+		items = append(items, it)
+	}
+	var runs []StoredRun
+	for _, byRun := range m.running {
+		for _, r := range byRun {
+			runs = append(runs, r)
+		}
+	}
+	return items, runs, nil
+}
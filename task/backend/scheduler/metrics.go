@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Metrics receives instrumentation events from the scheduler's dispatch
+// loop. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveScheduleLatency records how long an item waited between its
+	// computed next-fire time and the executor actually being invoked for
+	// it, partitioned by the priority it fired at.
+	ObserveScheduleLatency(prio Priority, latency time.Duration)
+	// ObserveRunDuration records how long a run took, from its Promise
+	// starting to its Done() channel closing.
+	ObserveRunDuration(taskID ID, d time.Duration)
+	// IncRunResult increments a counter of run outcomes for taskID,
+	// partitioned by a coarse status such as "success" or "error".
+	IncRunResult(taskID ID, status string)
+	// SetScheduledGauge reports the number of items currently waiting
+	// across all priority buckets.
+	SetScheduledGauge(n int)
+	// SetRunningGauge reports the number of runs currently in flight.
+	SetRunningGauge(n int)
+}
+
+// noopMetrics is the default Metrics: every call does nothing. It's used
+// until a WithMetrics option supplies a real implementation.
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveScheduleLatency(Priority, time.Duration) {}
+func (noopMetrics) ObserveRunDuration(ID, time.Duration)           {}
+func (noopMetrics) IncRunResult(ID, string)                       {}
+func (noopMetrics) SetScheduledGauge(int)                         {}
+func (noopMetrics) SetRunningGauge(int)                           {}
+
+// WithMetrics wires m into the scheduler in place of the default no-op.
+func WithMetrics(m Metrics) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		t.metrics = m
+		return nil
+	}
+}
+
+// RunRecord is a structured summary of one completed run, emitted to a
+// RunHistorySink after the run finishes.
+type RunRecord struct {
+	TaskID      ID
+	RunID       ID
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Err         error
+}
+
+// RunHistorySink receives a RunRecord for every run the scheduler
+// completes, successfully or not. This is what lets the UI show per-task
+// success/failure history without polling the scheduler's internals.
+type RunHistorySink interface {
+	RecordRun(record RunRecord)
+}
+
+// WithRunHistory wires sink into the scheduler; every completed or failed
+// run is emitted to it once it finishes.
+func WithRunHistory(sink RunHistorySink) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		t.runHistory = sink
+		return nil
+	}
+}
+
+// errorClass derives the IncRunResult status label for a run's error:
+// "success" for nil, "canceled"/"deadline_exceeded" for context
+// cancellation, or "error" for everything else. Keeping the label set
+// small avoids unbounded cardinality in the status dimension.
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+// Priority is the scheduling class a run is placed in.  Within a single
+// wake-up of the scheduler, due items in a higher priority bucket are always
+// drained before items in a lower priority one, regardless of how long the
+// lower priority items have been waiting.
+type Priority int
+
+const (
+	// PriorityNormal is the priority assigned to every run that gets to
+	// its scheduled time through the ordinary cron cursor.
+	PriorityNormal Priority = iota
+	// PriorityRetry is assigned to runs that are being rescheduled after
+	// onErr reported a failure.  It sits above Normal so a flapping task
+	// gets another attempt promptly, but below TryJob/Force so a storm of
+	// retries can't starve brand new work.
+	PriorityRetry
+	// PriorityTryJob is for ad-hoc or manually triggered runs that should
+	// be serviced ahead of regular cron traffic but shouldn't preempt a
+	// ForceRun.
+	PriorityTryJob
+	// PriorityForce is the priority ForceRun runs an item at. ForceRun
+	// invokes the executor directly rather than queueing the item, so the
+	// run starts before ForceRun returns. An item scheduled into this
+	// bucket by ScheduleWithPriority is drained ahead of every other
+	// bucket.
+	PriorityForce
+
+	// numPriorities is the number of priority buckets the scheduler keeps.
+	numPriorities = int(PriorityForce) + 1
+)
+
+// String renders the priority the way it would appear in logs or metrics
+// labels.
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityRetry:
+		return "retry"
+	case PriorityTryJob:
+		return "try_job"
+	case PriorityForce:
+		return "force"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// defaultBackoffBase is the starting delay used by DefaultBackoffPolicy.
+	defaultBackoffBase = 1 * time.Second
+	// defaultBackoffCap bounds how large DefaultBackoffPolicy's delay can
+	// grow, no matter how many times a task has failed.
+	defaultBackoffCap = 5 * time.Minute
+)
+
+// QuarantineDecision is returned by an ErrorFunc after a run fails, telling
+// the scheduler what to do about it.
+type QuarantineDecision struct {
+	// Retry asks the scheduler to reschedule the task at PriorityRetry,
+	// after the configured BackoffPolicy, unless Quarantine below also
+	// triggers.
+	Retry bool
+	// Quarantine asks the scheduler to pull the task out of the active
+	// priority buckets immediately, regardless of its failure history,
+	// until Resume is called or the configured auto-heal timeout elapses.
+	Quarantine bool
+}
+
+// BackoffPolicy computes how long to wait before retrying a task after a
+// run failure, given how many times it's been retried (failureCount, the
+// item's nonce: every retry increments it, and only a new Schedule or
+// ScheduleWithPriority call resets it; a successful run does not) and the
+// time its cron schedule would fire it again anyway (cronNext). It returns a delay, not an absolute time;
+// scheduleRetry adds it to "now" and takes the later of that and cronNext.
+type BackoffPolicy func(failureCount int, cronNext time.Time) time.Duration
+
+// DefaultBackoffPolicy is exponential backoff with full jitter, capped at
+// defaultBackoffCap: delay = random(0, min(cap, base*2^failureCount)).
+// cronNext is unused here; scheduleRetry itself takes care of never firing
+// a retry sooner than the task's own cron cadence would.
+func DefaultBackoffPolicy(failureCount int, cronNext time.Time) time.Duration {
+	backoff := defaultBackoffBase * time.Duration(int64(1)<<uint(failureCount))
+	if backoff <= 0 || backoff > defaultBackoffCap {
+		backoff = defaultBackoffCap
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// taskFailures is a fixed-size ring buffer of a single task's most recent
+// run failures, used to decide when it should be quarantined. Modeled on
+// swarmkit's node-failure lookback (monitorFailures/maxFailures), but
+// scoped to one task instead of one node.
+type taskFailures struct {
+	at   []time.Time
+	errs []error
+	pos  int
+}
+
+// newTaskFailures returns a taskFailures that remembers the last n
+// failures.
+func newTaskFailures(n int) *taskFailures {
+	return &taskFailures{at: make([]time.Time, 0, n), errs: make([]error, 0, n)}
+}
+
+// record appends a failure, overwriting the oldest one once the buffer is
+// full.
+func (f *taskFailures) record(at time.Time, err error) {
+	if len(f.at) < cap(f.at) {
+		f.at = append(f.at, at)
+		f.errs = append(f.errs, err)
+		return
+	}
+	f.at[f.pos] = at
+	f.errs[f.pos] = err
+	f.pos = (f.pos + 1) % cap(f.at)
+}
+
+// full reports whether the buffer has recorded cap(f.at) failures yet.
+func (f *taskFailures) full() bool {
+	return len(f.at) == cap(f.at)
+}
+
+// withinWindow reports whether every recorded failure happened within
+// window of now, meaning the task has failed cap(f.at) times in the last
+// window and should be quarantined.
+func (f *taskFailures) withinWindow(now time.Time, window time.Duration) bool {
+	if !f.full() {
+		return false
+	}
+	for _, at := range f.at {
+		if now.Sub(at) > window {
+			return false
+		}
+	}
+	return true
+}
+
+// quarantinedTask is a task that's been pulled out of the active priority
+// buckets after failing too many times in too short a window.
+type quarantinedTask struct {
+	item             item
+	quarantinedAt    time.Time
+	quarantinedUntil time.Time
+	failures         *taskFailures
+}
+
+// recordFailure records runErr against it.id's failure window and decides
+// what happens next: quarantine (if decision.Quarantine, or if quarantine
+// is enabled and the window is now full of failures), retry at
+// PriorityRetry (if decision.Retry and the task wasn't quarantined), or
+// leave the task to whatever its already-queued next cron occurrence is.
+func (s *TreeScheduler) recordFailure(it item, now time.Time, runErr error, decision QuarantineDecision) {
+	quarantine := decision.Quarantine
+
+	if s.quarantineMaxFailures > 0 {
+		s.Lock()
+		fw, ok := s.failures[it.id]
+		if !ok {
+			fw = newTaskFailures(s.quarantineMaxFailures)
+			s.failures[it.id] = fw
+		}
+		fw.record(now, runErr)
+		if fw.withinWindow(now, s.quarantineWindow) {
+			quarantine = true
+		}
+		s.Unlock()
+	}
+
+	if quarantine {
+		s.quarantine(it, now)
+		return
+	}
+	if decision.Retry {
+		s.scheduleRetry(it)
+	}
+}
+
+// quarantinedStoredItem builds the durable representation of a quarantined
+// task. Unlike an active item, a quarantined one is kept in the store
+// rather than deleted, so it isn't lost if the process restarts before
+// Resume is called or its auto-heal deadline elapses.
+func quarantinedStoredItem(it item, at, until time.Time) StoredItem {
+	si := it.stored()
+	si.Quarantined = true
+	si.QuarantinedAt = at
+	si.QuarantinedUntil = until
+	return si
+}
+
+// quarantine removes it.id's pending entry from the active buckets, if any,
+// and parks it in s.quarantined until Resume is called or its auto-heal
+// deadline elapses. The quarantine is mirrored to the store in the same
+// critical section, so a restart while the task is quarantined finds it
+// still there instead of having lost it along with its deleted active
+// entry.
+func (s *TreeScheduler) quarantine(it item, now time.Time) {
+	until := now.Add(s.quarantineAutoHeal)
+
+	s.Lock()
+	s.deleteScheduledLocked(it.id)
+	s.quarantined[it.id] = &quarantinedTask{
+		item:             it,
+		quarantinedAt:    now,
+		quarantinedUntil: until,
+		failures:         s.failures[it.id],
+	}
+	s.bumpWakeLocked(until)
+	_ = s.store.UpsertScheduled(context.Background(), quarantinedStoredItem(it, now, until))
+	s.Unlock()
+}
+
+// healQuarantinedLocked moves every quarantined task whose auto-heal
+// deadline has passed back into the active buckets at PriorityRetry, and
+// mirrors that back to the store so the quarantine doesn't reappear on the
+// next restart. Callers must hold s.Lock().
+func (s *TreeScheduler) healQuarantinedLocked(now time.Time) {
+	for id, q := range s.quarantined {
+		if q.quarantinedUntil.IsZero() || now.Before(q.quarantinedUntil) {
+			continue
+		}
+		delete(s.quarantined, id)
+		delete(s.failures, id)
+
+		it := q.item
+		it.prio = PriorityRetry
+		it.next = now.Unix()
+		s.insertScheduledLocked(it)
+		_ = s.store.UpsertScheduled(context.Background(), it.stored())
+	}
+}
+
+// clearQuarantineLocked drops any quarantine state id has, without touching
+// the active buckets or the store. Callers that are about to replace id's
+// state outright (Release, ScheduleWithPriority) must call this too, or a
+// stale s.quarantined entry survives and healQuarantinedLocked later
+// resurrects it out from under the caller once its auto-heal deadline
+// elapses. Callers must hold s.Lock().
+func (s *TreeScheduler) clearQuarantineLocked(id ID) {
+	delete(s.quarantined, id)
+	delete(s.failures, id)
+}
+
+// Resume takes a task out of quarantine immediately and returns it to the
+// active buckets at PriorityRetry, so it gets another chance promptly but
+// still behind any TryJob/Force traffic. It is a no-op if taskID isn't
+// currently quarantined.
+func (s *TreeScheduler) Resume(taskID ID) error {
+	s.Lock()
+	defer s.Unlock()
+
+	q, ok := s.quarantined[taskID]
+	if !ok {
+		return nil
+	}
+	delete(s.quarantined, taskID)
+	delete(s.failures, taskID)
+
+	it := q.item
+	it.prio = PriorityRetry
+	it.next = s.time.Now().Unix()
+	s.insertScheduledLocked(it)
+	s.bumpWakeLocked(time.Unix(it.next, 0))
+
+	return s.store.UpsertScheduled(context.Background(), it.stored())
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/influxdata/cron"
+)
+
+// testCron returns a real parsed cron schedule for fixture items, like the
+// one ScheduleWithPriority gives every item. The scheduler's dispatch
+// goroutines are live in these tests (newTestScheduler builds a real
+// *TreeScheduler via NewScheduler), and a due item with a zero-value
+// cron.Parsed is not requeued: requeueNext reports it to onErr instead, and
+// scheduleRetry falls back to now in place of its cron.Next (see
+// item.hasCron).
+func testCron(t *testing.T) cron.Parsed {
+	t.Helper()
+	c, err := cron.ParseUTC("@every 1h")
+	if err != nil {
+		t.Fatalf("cron.ParseUTC: %v", err)
+	}
+	return c
+}
+
+func TestDefaultBackoffPolicyIsBoundedAndGrows(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if d := DefaultBackoffPolicy(2, time.Time{}); d < 0 || d > defaultBackoffCap {
+			t.Fatalf("DefaultBackoffPolicy(2, ...) = %v, want within [0, %v]", d, defaultBackoffCap)
+		}
+	}
+
+	// A failureCount large enough to overflow the 1<<failureCount shift
+	// must still clamp to the cap rather than wrapping into a tiny or
+	// negative duration.
+	for i := 0; i < 1000; i++ {
+		if d := DefaultBackoffPolicy(100, time.Time{}); d < 0 || d > defaultBackoffCap {
+			t.Fatalf("DefaultBackoffPolicy(100, ...) = %v, want within [0, %v]", d, defaultBackoffCap)
+		}
+	}
+
+	// At a high enough failure count the backoff should be saturated at
+	// the cap, so samples should climb well above what a small, unsaturated
+	// failureCount would produce.
+	sawLarge := false
+	for i := 0; i < 1000; i++ {
+		if DefaultBackoffPolicy(100, time.Time{}) > defaultBackoffCap/2 {
+			sawLarge = true
+			break
+		}
+	}
+	if !sawLarge {
+		t.Fatal("DefaultBackoffPolicy(100, ...) never sampled above half the cap across 1000 tries")
+	}
+}
+
+func TestTaskFailuresWithinWindow(t *testing.T) {
+	f := newTaskFailures(3)
+	now := time.Unix(1000, 0)
+
+	if f.full() {
+		t.Fatal("newTaskFailures(3): full() should be false before any record")
+	}
+	if f.withinWindow(now, time.Minute) {
+		t.Fatal("withinWindow: should be false before the buffer fills")
+	}
+
+	f.record(now, errors.New("e1"))
+	f.record(now.Add(time.Second), errors.New("e2"))
+	if f.full() {
+		t.Fatal("full() should be false with 2 of 3 slots filled")
+	}
+
+	f.record(now.Add(2*time.Second), errors.New("e3"))
+	if !f.full() {
+		t.Fatal("full() should be true once 3 of 3 slots are filled")
+	}
+	if !f.withinWindow(now.Add(10*time.Second), time.Minute) {
+		t.Fatal("withinWindow: all 3 failures are within the last minute")
+	}
+	if f.withinWindow(now.Add(10*time.Second), time.Second) {
+		t.Fatal("withinWindow: the oldest failure is outside a 1-second window")
+	}
+
+	// Overwrites the oldest slot (e1); the window should now be anchored on
+	// e2 instead, not e1.
+	f.record(now.Add(100*time.Second), errors.New("e4"))
+	if f.withinWindow(now.Add(101*time.Second), 90*time.Second) {
+		t.Fatal("withinWindow: e2 at now+1s is still outside a 90s window measured from now+101s")
+	}
+}
+
+func TestRecordFailureQuarantinesAfterWindowFills(t *testing.T) {
+	s := newTestScheduler(t, WithQuarantine(1, time.Minute, time.Hour))
+	now := time.Unix(1000, 0)
+	it := item{id: ID(1), next: now.Unix(), prio: PriorityNormal, cron: testCron(t)}
+
+	s.Lock()
+	s.insertScheduledLocked(it)
+	s.Unlock()
+
+	// quarantineMaxFailures is 1, so the very first failure already fills
+	// the window and quarantines the task; scheduleRetry (and its cron.Next
+	// call) is never reached.
+	s.recordFailure(it, now, errors.New("boom"), QuarantineDecision{Retry: true})
+
+	s.Lock()
+	_, stillScheduled := s.nextTime[it.id]
+	q, quarantined := s.quarantined[it.id]
+	s.Unlock()
+
+	if stillScheduled {
+		t.Fatal("recordFailure: task should have been removed from the active buckets")
+	}
+	if !quarantined {
+		t.Fatal("recordFailure: task should be quarantined after filling its failure window")
+	}
+	if q.item.id != it.id {
+		t.Fatalf("quarantined task id = %v, want %v", q.item.id, it.id)
+	}
+}
+
+func TestResumeReturnsTaskToActiveBuckets(t *testing.T) {
+	s := newTestScheduler(t, WithQuarantine(1, time.Minute, time.Hour))
+	now := time.Unix(1000, 0)
+	it := item{id: ID(1), next: now.Unix(), prio: PriorityNormal, cron: testCron(t)}
+
+	s.Lock()
+	s.quarantined[it.id] = &quarantinedTask{item: it, quarantinedAt: now, quarantinedUntil: now.Add(time.Hour)}
+	s.Unlock()
+
+	if err := s.Resume(it.id); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	s.Lock()
+	_, stillQuarantined := s.quarantined[it.id]
+	meta, scheduled := s.nextTime[it.id]
+	s.Unlock()
+
+	if stillQuarantined {
+		t.Fatal("Resume: task should no longer be quarantined")
+	}
+	if !scheduled {
+		t.Fatal("Resume: task should be back in the active buckets")
+	}
+	if meta.prio != PriorityRetry {
+		t.Fatalf("Resume: priority = %v, want %v", meta.prio, PriorityRetry)
+	}
+
+	// Resuming a task that isn't quarantined is a documented no-op.
+	if err := s.Resume(ID(999)); err != nil {
+		t.Fatalf("Resume on an unquarantined id: %v", err)
+	}
+}
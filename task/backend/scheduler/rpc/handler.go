@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// AuthFunc authenticates an incoming request, returning a context carrying
+// whatever the rest of the handler chain needs, or an error to reject the
+// call with 401. It's the same shape as influxdb's existing twirp_auth
+// middleware, so the server's existing authorizer can be plugged in here
+// unchanged.
+type AuthFunc func(ctx context.Context, r *http.Request) (context.Context, error)
+
+const pathPrefix = "/twirp/influxdata.scheduler.TaskScheduler/"
+
+// NewHandler returns an http.Handler serving sched's control-plane methods
+// over Twirp's JSON wire protocol, at
+// /twirp/influxdata.scheduler.TaskScheduler/<Method>. auth, if non-nil, runs
+// before every call and can reject it outright.
+func NewHandler(sched Scheduler, auth AuthFunc) http.Handler {
+	srv := NewServer(sched)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(pathPrefix+"Schedule", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(ScheduleRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.Schedule(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"Release", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(ReleaseRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.Release(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"ForceRun", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(ForceRunRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.ForceRun(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"ListScheduled", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(ListScheduledRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.ListScheduled(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"ListRunning", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(ListRunningRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.ListRunning(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"CancelRun", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(CancelRunRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.CancelRun(ctx, req)
+	}))
+	mux.HandleFunc(pathPrefix+"Runs", withAuth(auth, func(ctx context.Context, r *http.Request) (interface{}, error) {
+		req := new(RunsRequest)
+		if err := decode(r, req); err != nil {
+			return nil, err
+		}
+		return srv.Runs(ctx, req)
+	}))
+
+	return mux
+}
+
+// decode reads a JSON request body into req. A nil or empty body decodes
+// to the zero value, matching Twirp's own JSON codec.
+func decode(r *http.Request, req interface{}) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+	defer r.Body.Close()
+	return json.NewDecoder(r.Body).Decode(req)
+}
+
+// withAuth wraps a single Twirp method, running auth before call and
+// writing call's result or error as JSON.
+func withAuth(auth AuthFunc, call func(ctx context.Context, r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if auth != nil {
+			var err error
+			ctx, err = auth(ctx, r)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+		}
+
+		resp, err := call(ctx, r)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
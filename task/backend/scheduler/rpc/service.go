@@ -0,0 +1,146 @@
+// Package rpc exposes a scheduler.TreeScheduler over HTTP using the Twirp
+// wire protocol, so ops tools and the UI can drive it without being
+// in-process with the scheduler itself.
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/influxdata/influxdb/task/backend/scheduler"
+)
+
+// Scheduler is the subset of *scheduler.TreeScheduler the Server needs.
+// Handlers delegate to it directly; nothing here re-implements scheduling
+// logic.
+type Scheduler interface {
+	Schedule(id scheduler.ID, cronString string, offset time.Duration, since time.Time) error
+	ScheduleWithPriority(id scheduler.ID, cronString string, offset time.Duration, since time.Time, prio scheduler.Priority) error
+	Release(id scheduler.ID) error
+	ForceRun(id scheduler.ID) (scheduler.Promise, error)
+	Runs(taskID scheduler.ID, limit int) []scheduler.ID
+	ListScheduled() []scheduler.ScheduledItem
+	ListRunning() []scheduler.RunningItem
+	CancelRun(taskID, runID scheduler.ID) error
+}
+
+// ScheduleRequest schedules a task at the given priority. Priority is the
+// zero value (scheduler.PriorityNormal) unless set.
+type ScheduleRequest struct {
+	TaskID     uint64             `json:"taskID"`
+	CronString string             `json:"cronString"`
+	OffsetNS   int64              `json:"offsetNs"`
+	SinceUnix  int64              `json:"sinceUnix"`
+	Priority   scheduler.Priority `json:"priority"`
+}
+
+// ReleaseRequest releases a task from the scheduler.
+type ReleaseRequest struct {
+	TaskID uint64 `json:"taskID"`
+}
+
+// ForceRunRequest forces immediate execution of a task.
+type ForceRunRequest struct {
+	TaskID uint64 `json:"taskID"`
+}
+
+// ForceRunResponse reports the run that ForceRun started. Started is false
+// if the executor declined to start a run (scheduler.TreeScheduler.ForceRun
+// returns a nil Promise for that, not an error), in which case RunID is
+// meaningless.
+type ForceRunResponse struct {
+	Started bool   `json:"started"`
+	RunID   uint64 `json:"runID"`
+}
+
+// ListScheduledRequest has no parameters; it's a struct so the transport
+// stays uniform across methods.
+type ListScheduledRequest struct{}
+
+// ListScheduledResponse lists every task currently pending in a priority
+// bucket.
+type ListScheduledResponse struct {
+	Items []scheduler.ScheduledItem `json:"items"`
+}
+
+// ListRunningRequest has no parameters; it's a struct so the transport
+// stays uniform across methods.
+type ListRunningRequest struct{}
+
+// ListRunningResponse lists every run currently in flight.
+type ListRunningResponse struct {
+	Items []scheduler.RunningItem `json:"items"`
+}
+
+// CancelRunRequest cancels a single in-flight run.
+type CancelRunRequest struct {
+	TaskID uint64 `json:"taskID"`
+	RunID  uint64 `json:"runID"`
+}
+
+// RunsRequest lists the run IDs currently in flight for a task. Limit is
+// passed through to Runs and does not cap the result.
+type RunsRequest struct {
+	TaskID uint64 `json:"taskID"`
+	Limit  int    `json:"limit"`
+}
+
+// RunsResponse lists the run IDs currently in flight for a task.
+type RunsResponse struct {
+	RunIDs []uint64 `json:"runIDs"`
+}
+
+// Server implements the scheduler control-plane service by delegating
+// directly to a Scheduler; it holds no scheduling state of its own.
+type Server struct {
+	sched Scheduler
+}
+
+// NewServer returns a Server backed by sched.
+func NewServer(sched Scheduler) *Server {
+	return &Server{sched: sched}
+}
+
+func (s *Server) Schedule(ctx context.Context, req *ScheduleRequest) (*struct{}, error) {
+	since := time.Unix(req.SinceUnix, 0)
+	err := s.sched.ScheduleWithPriority(scheduler.ID(req.TaskID), req.CronString, time.Duration(req.OffsetNS), since, req.Priority)
+	return &struct{}{}, err
+}
+
+func (s *Server) Release(ctx context.Context, req *ReleaseRequest) (*struct{}, error) {
+	err := s.sched.Release(scheduler.ID(req.TaskID))
+	return &struct{}{}, err
+}
+
+func (s *Server) ForceRun(ctx context.Context, req *ForceRunRequest) (*ForceRunResponse, error) {
+	prom, err := s.sched.ForceRun(scheduler.ID(req.TaskID))
+	if err != nil {
+		return nil, err
+	}
+	if prom == nil {
+		return &ForceRunResponse{}, nil
+	}
+	return &ForceRunResponse{Started: true, RunID: uint64(prom.ID())}, nil
+}
+
+func (s *Server) ListScheduled(ctx context.Context, _ *ListScheduledRequest) (*ListScheduledResponse, error) {
+	return &ListScheduledResponse{Items: s.sched.ListScheduled()}, nil
+}
+
+func (s *Server) ListRunning(ctx context.Context, _ *ListRunningRequest) (*ListRunningResponse, error) {
+	return &ListRunningResponse{Items: s.sched.ListRunning()}, nil
+}
+
+func (s *Server) CancelRun(ctx context.Context, req *CancelRunRequest) (*struct{}, error) {
+	err := s.sched.CancelRun(scheduler.ID(req.TaskID), scheduler.ID(req.RunID))
+	return &struct{}{}, err
+}
+
+func (s *Server) Runs(ctx context.Context, req *RunsRequest) (*RunsResponse, error) {
+	ids := s.sched.Runs(scheduler.ID(req.TaskID), req.Limit)
+	resp := &RunsResponse{RunIDs: make([]uint64, len(ids))}
+	for i, id := range ids {
+		resp.RunIDs[i] = uint64(id)
+	}
+	return resp, nil
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/google/btree"
+)
+
+// numRunningShards is the number of independent locks guarding the running
+// set. Splitting running into shards keyed by taskID % numRunningShards
+// means two workers completing runs for unrelated tasks don't serialize on
+// the same mutex, which starts to matter once WithWorkers lets runs
+// complete concurrently instead of one at a time off a single dispatch
+// goroutine.
+const numRunningShards = 32
+
+// runningShard is one stripe of the running set: its own btree plus the
+// mutex guarding it, independent of TreeScheduler's main RWMutex.
+type runningShard struct {
+	mu   sync.Mutex
+	tree btree.BTree
+}
+
+func (s *TreeScheduler) runningShardFor(taskID ID) *runningShard {
+	return &s.running[uint64(taskID)%numRunningShards]
+}
+
+// runningInsert records a newly started run.
+func (s *TreeScheduler) runningInsert(it runningItem) {
+	sh := s.runningShardFor(it.taskID)
+	sh.mu.Lock()
+	sh.tree.ReplaceOrInsert(it)
+	sh.mu.Unlock()
+}
+
+// runningDelete removes a finished run.
+func (s *TreeScheduler) runningDelete(it runningItem) {
+	sh := s.runningShardFor(it.taskID)
+	sh.mu.Lock()
+	sh.tree.Delete(it)
+	sh.mu.Unlock()
+}
+
+// runningGet looks up a single in-flight run.
+func (s *TreeScheduler) runningGet(taskID, runID ID) (runningItem, bool) {
+	sh := s.runningShardFor(taskID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	i := sh.tree.Get(runningItem{taskID: taskID, runID: runID})
+	if i == nil {
+		return runningItem{}, false
+	}
+	return i.(runningItem), true
+}
+
+// runningLen returns a snapshot count of in-flight runs across all shards.
+func (s *TreeScheduler) runningLen() int {
+	n := 0
+	for i := range s.running {
+		s.running[i].mu.Lock()
+		n += s.running[i].tree.Len()
+		s.running[i].mu.Unlock()
+	}
+	return n
+}
+
+// runningAll returns a snapshot of every in-flight run across all shards.
+func (s *TreeScheduler) runningAll() []runningItem {
+	items := make([]runningItem, 0)
+	for i := range s.running {
+		s.running[i].mu.Lock()
+		s.running[i].tree.Ascend(func(bi btree.Item) bool {
+			items = append(items, bi.(runningItem))
+			return true
+		})
+		s.running[i].mu.Unlock()
+	}
+	return items
+}
+
+// runningClearTask removes every in-flight run for taskID from its shard.
+func (s *TreeScheduler) runningClearTask(taskID ID) {
+	sh := s.runningShardFor(taskID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	// Collect first: deleting from the btree while ascending it can skip
+	// items.
+	var del []runningItem
+	sh.tree.AscendGreaterOrEqual(runningItem{taskID: taskID}, func(i btree.Item) bool {
+		ri := i.(runningItem)
+		if ri.taskID != taskID {
+			return false
+		}
+		del = append(del, ri)
+		return true
+	})
+	for _, ri := range del {
+		sh.tree.Delete(ri)
+	}
+}
+
+// runningIDs returns the IDs of every run currently in flight for taskID.
+// limit only sizes the initial slice; it does not cap the result.
+func (s *TreeScheduler) runningIDs(taskID ID, limit int) []ID {
+	sh := s.runningShardFor(taskID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	acc := make([]ID, 0, limit)
+	sh.tree.AscendGreaterOrEqual(runningItem{taskID: taskID}, func(i btree.Item) bool {
+		ri := i.(runningItem)
+		if ri.taskID != taskID {
+			return false
+		}
+		acc = append(acc, ri.runID)
+		return true
+	})
+	return acc
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/influxdata/cron"
+)
+
+// errRestarted is recorded against any run that was still in flight in the
+// store when the scheduler started up; its Promise and cancel func died
+// with the previous process, so there's nothing left to track.
+var errRestarted = errors.New("scheduler restarted before run completed")
+
+// SchedulerStore persists the scheduler's cron cursors and in-flight run
+// bookkeeping, so a process restart doesn't lose every cron cursor or
+// orphan a run that was mid-flight when it went down. Implementations must
+// be safe for concurrent use; TreeScheduler calls into the store from
+// inside its own critical section, so a slow store directly slows down
+// scheduling.
+type SchedulerStore interface {
+	// UpsertScheduled persists it, replacing whatever was previously
+	// stored for it.TaskID.
+	UpsertScheduled(ctx context.Context, it StoredItem) error
+	// DeleteScheduled removes any persisted entry for taskID.
+	DeleteScheduled(ctx context.Context, taskID ID) error
+	// RecordRun persists that runID for taskID started at startedAt. It is
+	// called once the executor has returned the run's Promise, before the
+	// run is added to the running set.
+	RecordRun(ctx context.Context, taskID, runID ID, startedAt time.Time) error
+	// CompleteRun marks runID for taskID finished, so LoadAll no longer
+	// returns it. runErr is the run's error, or nil; the stores in this
+	// package (NewKVStore, NewInMemStore) discard it.
+	CompleteRun(ctx context.Context, taskID, runID ID, runErr error) error
+	// LoadAll returns every persisted scheduled item and every run that was
+	// recorded but never completed, so NewScheduler can repopulate the tree
+	// and reconcile in-flight runs on startup.
+	LoadAll(ctx context.Context) ([]StoredItem, []StoredRun, error)
+}
+
+// StoredItem is the durable representation of a single task's pending entry
+// in a priority bucket, or of a quarantined task.
+type StoredItem struct {
+	TaskID     ID
+	CronString string
+	Offset     time.Duration
+	Next       time.Time
+	Nonce      int
+	Priority   Priority
+
+	// Quarantined, QuarantinedAt and QuarantinedUntil are set when the task
+	// is parked in quarantine rather than sitting in an active priority
+	// bucket. A quarantined item is kept in the store rather than deleted,
+	// so a restart while a task is quarantined doesn't lose it outright.
+	Quarantined      bool
+	QuarantinedAt    time.Time
+	QuarantinedUntil time.Time
+}
+
+// StoredRun is the durable representation of a run that was started but not
+// yet known to have completed.
+type StoredRun struct {
+	TaskID    ID
+	RunID     ID
+	StartedAt time.Time
+}
+
+// WithStore wires a SchedulerStore into the scheduler. On construction the
+// tree is repopulated from store.LoadAll, and every run LoadAll reports as
+// still in flight is completed in the store with errRestarted rather than
+// put back in the running set; after that,
+// every Schedule, Release, and run completion is mirrored to the store
+// inside the same critical section that updates the in-memory state.
+func WithStore(store SchedulerStore) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		t.store = store
+		return nil
+	}
+}
+
+// loadFromStore repopulates the tree from store.LoadAll. It is called once,
+// during NewScheduler, before the dispatch goroutine starts.
+func (s *TreeScheduler) loadFromStore(ctx context.Context) error {
+	items, runs, err := s.store.LoadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, si := range items {
+		crSch, err := cron.ParseUTC(si.CronString)
+		if err != nil {
+			s.onErr(ctx, si.TaskID, 0, si.Next, err)
+			continue
+		}
+		it := item{
+			cron:       crSch,
+			cronString: si.CronString,
+			next:       si.Next.Unix(),
+			nonce:      si.Nonce,
+			id:         si.TaskID,
+			prio:       si.Priority,
+		}
+		if si.Quarantined {
+			s.quarantined[it.id] = &quarantinedTask{
+				item:             it,
+				quarantinedAt:    si.QuarantinedAt,
+				quarantinedUntil: si.QuarantinedUntil,
+			}
+			continue
+		}
+		s.scheduled[it.prio].ReplaceOrInsert(it)
+		s.nextTime[it.id] = scheduledMeta{next: it.next, nonce: it.nonce, prio: it.prio}
+	}
+
+	// Runs that were recorded but never completed died with the previous
+	// process. There's no live Promise or cancel func to reattach to, so
+	// reconcile the store rather than leave them as orphans forever; the
+	// task's own cron cursor, already restored above, will fire again on
+	// its normal cadence.
+	for _, r := range runs {
+		if err := s.store.CompleteRun(ctx, r.TaskID, r.RunID, errRestarted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
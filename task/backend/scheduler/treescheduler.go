@@ -25,54 +25,54 @@ func (it runningItem) Less(bItem btree.Item) bool {
 	return it.taskID < it2.taskID || (it.taskID == it2.taskID && it.runID < it2.runID)
 }
 
+// scheduledMeta records where in the priority buckets a task's single
+// pending entry lives, so it can be located and removed without a linear
+// scan of the buckets.
+type scheduledMeta struct {
+	next  int64
+	nonce int
+	prio  Priority
+}
+
 // TreeScheduler is a Scheduler based on a btree
 type TreeScheduler struct {
 	sync.RWMutex
-	scheduled btree.BTree
-	running   btree.BTree
-	nextTime  map[ID]int64 // we need this index so we can delete items from the scheduled
-	when      time.Time
-	executor  func(ctx context.Context, id ID, scheduledAt time.Time) (Promise, error)
-	onErr     func(ctx context.Context, taskID ID, runID ID, scheduledAt time.Time, err error) bool
-	time      Time
-	timer     *time.Timer
-	done      chan struct{}
-	sema      chan struct{}
-	wg        sync.WaitGroup
-}
-
-// clearTask is a method for deleting a range of tasks.
-// TODO(docmerlin): add an actual ranged delete to github.com/google/btree
-func (s *TreeScheduler) clearTask(taskID ID) btree.ItemIterator {
-	return func(i btree.Item) bool {
-		del := i.(runningItem).taskID == taskID
-		if !del {
-			return false
-		}
-		s.running.Delete(runningItem{taskID: taskID})
-		return true
-	}
-}
-
-// runs is a method for accumulating the running runs of a task.
-func (s *TreeScheduler) runs(taskID ID, limit int) (btree.ItemIterator, []ID) {
-	acc := make([]ID, 0, limit)
-	return func(i btree.Item) bool {
-		ritem := i.(runningItem)
-		match := ritem.taskID == taskID
-		if !match {
-			return false
-		}
-		acc = append(acc, ritem.runID)
-		return true
-	}, acc
+	scheduled    [numPriorities]btree.BTree
+	running      [numRunningShards]runningShard
+	nextTime     map[ID]scheduledMeta // we need this index so we can delete items from the scheduled
+	when         time.Time
+	executor     func(ctx context.Context, id ID, scheduledAt time.Time) (Promise, error)
+	onErr        func(ctx context.Context, taskID ID, runID ID, scheduledAt time.Time, err error) QuarantineDecision
+	backoff      BackoffPolicy
+	store        SchedulerStore
+	metrics      Metrics
+	runHistory   RunHistorySink
+	time         Time
+	timer        *time.Timer
+	done         chan struct{}
+	sema         chan struct{}
+	numWorkers   int
+	jobs         chan item
+	wg           sync.WaitGroup
+
+	quarantineMaxFailures int
+	quarantineWindow      time.Duration
+	quarantineAutoHeal    time.Duration
+	quarantined           map[ID]*quarantinedTask
+	failures              map[ID]*taskFailures
 }
 
 const maxWaitTime = 1000000 * time.Hour
 
+// btreeDegree is the degree passed to btree.New for every btree.BTree this
+// package constructs. The zero value of btree.BTree is not usable (it has
+// no copy-on-write context), so every bucket must be built through
+// btree.New rather than left at its zero value.
+const btreeDegree = 32
+
 type ExecutorFunc func(ctx context.Context, id ID, scheduledAt time.Time) (Promise, error)
 
-type ErrorFunc func(ctx context.Context, taskID ID, runID ID, scheduledAt time.Time, err error) bool
+type ErrorFunc func(ctx context.Context, taskID ID, runID ID, scheduledAt time.Time, err error) QuarantineDecision
 
 type treeSchedulerOptFunc func(t *TreeScheduler) error
 
@@ -97,13 +97,50 @@ func WithTime(t Time) treeSchedulerOptFunc {
 	}
 }
 
+// WithBackoff overrides the default retry backoff policy. See BackoffPolicy
+// and DefaultBackoffPolicy.
+func WithBackoff(policy BackoffPolicy) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		t.backoff = policy
+		return nil
+	}
+}
+
+// WithQuarantine enables automatic quarantine: a task whose run fails
+// maxFailures times within window is pulled out of the active priority
+// buckets until Resume is called or autoHeal elapses. maxFailures <= 0
+// disables quarantine entirely, which is the default.
+func WithQuarantine(maxFailures int, window, autoHeal time.Duration) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		t.quarantineMaxFailures = maxFailures
+		t.quarantineWindow = window
+		t.quarantineAutoHeal = autoHeal
+		return nil
+	}
+}
+
 // Executor is any function that accepts an ID, a time, and a duration.
 // OnErr is a function that takes am error, it is called when we cannot find a viable time before jan 1, 2100.  The default behavior is to drop the task on error.
 func NewScheduler(Executor ExecutorFunc, opts ...treeSchedulerOptFunc) (*TreeScheduler, error) {
 	s := &TreeScheduler{
 		executor: Executor,
-		onErr:    func(_ context.Context, _ ID, _ ID, _ time.Time, _ error) bool { return true },
-		sema:     make(chan struct{}, defaultMaxRunsOutstanding),
+		onErr: func(_ context.Context, _ ID, _ ID, _ time.Time, _ error) QuarantineDecision {
+			return QuarantineDecision{Retry: true}
+		},
+		sema:        make(chan struct{}, defaultMaxRunsOutstanding),
+		nextTime:    make(map[ID]scheduledMeta),
+		backoff:     DefaultBackoffPolicy,
+		store:       NewInMemStore(),
+		metrics:     noopMetrics{},
+		done:        make(chan struct{}),
+		quarantined: make(map[ID]*quarantinedTask),
+		failures:    make(map[ID]*taskFailures),
+	}
+	for p := range s.scheduled {
+		s.scheduled[p] = *btree.New(btreeDegree)
+	}
+	for i := range s.running {
+		s.running[i].tree = *btree.New(btreeDegree)
 	}
 
 	// apply options
@@ -113,78 +150,241 @@ func NewScheduler(Executor ExecutorFunc, opts ...treeSchedulerOptFunc) (*TreeSch
 		}
 	}
 
+	if err := s.loadFromStore(context.Background()); err != nil {
+		return nil, err
+	}
+
 	s.when = time.Now().Add(maxWaitTime)
-	s.timer = time.NewTimer(time.Until(s.when)) //time.Until(s.when))
+	s.timer = time.NewTimer(time.Until(s.when))
 	if Executor == nil {
 		return nil, errors.New("Executor must be a nnon-nil function")
 	}
+	if s.numWorkers < 1 {
+		s.numWorkers = defaultWorkers
+	}
+	s.jobs = make(chan item, s.numWorkers)
+
+	// items repopulated from the store above may already be due, or due
+	// sooner than maxWaitTime out, so the timer needs to account for them
+	// before the dispatch goroutine starts.
+	s.Lock()
+	s.resetWakeLocked()
+	s.Unlock()
+
+	for i := 0; i < s.numWorkers; i++ {
+		s.wg.Add(1)
+		go s.workerLoop()
+	}
+	s.wg.Add(1)
+	go s.dispatchLoop()
+	return s, nil
+}
+
+// popDueLocked returns the highest-priority item across all buckets whose
+// next fire time is at or before now, removing it from its bucket. It
+// returns false if nothing is due yet. Callers must hold s.Lock().
+func (s *TreeScheduler) popDueLocked(now time.Time) (item, bool) {
+	nowUnix := now.Unix()
+	for p := numPriorities - 1; p >= 0; p-- {
+		minI := s.scheduled[p].Min()
+		if minI == nil {
+			continue
+		}
+		it := minI.(item)
+		if it.next > nowUnix {
+			continue
+		}
+		s.scheduled[p].DeleteMin()
+		delete(s.nextTime, it.id)
+		return it, true
+	}
+	return item{}, false
+}
+
+// nextWakeLocked returns the soonest next fire time across all priority
+// buckets. Callers must hold s.Lock().
+func (s *TreeScheduler) nextWakeLocked() (time.Time, bool) {
+	var soonest int64
+	found := false
+	for p := 0; p < numPriorities; p++ {
+		minI := s.scheduled[p].Min()
+		if minI == nil {
+			continue
+		}
+		it := minI.(item)
+		if !found || it.next < soonest {
+			soonest = it.next
+			found = true
+		}
+	}
+	// A quarantined task's auto-heal deadline also needs to wake the
+	// scheduler, otherwise a quarantine issued while the buckets are
+	// otherwise empty would sleep past it forever.
+	for _, q := range s.quarantined {
+		if q.quarantinedUntil.IsZero() {
+			continue
+		}
+		t := q.quarantinedUntil.Unix()
+		if !found || t < soonest {
+			soonest = t
+			found = true
+		}
+	}
+	if !found {
+		return time.Time{}, false
+	}
+	return time.Unix(soonest, 0), true
+}
+
+// resetWakeLocked reprograms the timer to fire at the soonest pending item
+// across all buckets, or maxWaitTime out if nothing is scheduled. Callers
+// must hold s.Lock().
+func (s *TreeScheduler) resetWakeLocked() {
+	if w, ok := s.nextWakeLocked(); ok {
+		s.when = w
+	} else {
+		s.when = time.Now().Add(maxWaitTime)
+	}
+	s.timer.Reset(time.Until(s.when))
+}
+
+// bumpWakeLocked makes sure the scheduler timer won't sleep past t. Callers
+// must hold s.Lock().
+func (s *TreeScheduler) bumpWakeLocked(t time.Time) {
+	if !t.Before(s.when) {
+		return
+	}
+	s.when = t
+	if !s.timer.Stop() {
+		select {
+		case <-s.timer.C:
+		default:
+		}
+	}
+	s.timer.Reset(time.Until(s.when))
+}
+
+// deleteScheduledLocked removes id's pending entry, if any, from its
+// priority bucket, returning it. Callers must hold s.Lock().
+func (s *TreeScheduler) deleteScheduledLocked(id ID) (item, bool) {
+	meta, ok := s.nextTime[id]
+	if !ok {
+		return item{}, false
+	}
+	delete(s.nextTime, id)
+	key := item{next: meta.next, nonce: meta.nonce, id: id}
+	old := s.scheduled[meta.prio].Delete(key)
+	s.metrics.SetScheduledGauge(len(s.nextTime))
+	if old == nil {
+		return item{}, false
+	}
+	return old.(item), true
+}
+
+// insertScheduledLocked adds it to its priority's bucket and records its
+// location in nextTime, replacing any entry id already had. Callers must
+// hold s.Lock().
+func (s *TreeScheduler) insertScheduledLocked(it item) {
+	s.scheduled[it.prio].ReplaceOrInsert(it)
+	s.nextTime[it.id] = scheduledMeta{next: it.next, nonce: it.nonce, prio: it.prio}
+	s.metrics.SetScheduledGauge(len(s.nextTime))
+}
+
+// runItem invokes the executor for it, tracks the resulting run in running,
+// and spawns the goroutine that waits on its Promise and schedules a retry
+// on failure. It is shared by the dispatch loop and ForceRun.
+func (s *TreeScheduler) runItem(ctx context.Context, it item) (Promise, error) {
+	scheduledAt := time.Unix(it.next, 0)
+	s.metrics.ObserveScheduleLatency(it.prio, s.time.Now().Sub(scheduledAt))
+
+	prom, err := s.executor(ctx, it.id, scheduledAt)
+	if err != nil {
+		return nil, err
+	}
+	if prom == nil {
+		return nil, nil
+	}
+
+	startedAt := s.time.Now()
+	if err := s.store.RecordRun(ctx, it.id, prom.ID(), startedAt); err != nil {
+		return nil, err
+	}
+
+	s.runningInsert(runningItem{cancel: prom.Cancel, runID: prom.ID(), taskID: it.id})
+	s.metrics.SetRunningGauge(s.runningLen())
+
+	s.wg.Add(1)
+	s.sema <- struct{}{}
 	go func() {
-		for {
-			select {
-			case <-s.done:
-				s.Lock()
-				s.timer.Stop()
-				s.Unlock()
-				close(s.sema)
-				return
-			case <-s.timer.C:
-				iti := s.scheduled.DeleteMin()
-				if iti == nil {
-					s.Lock()
-					s.timer.Reset(maxWaitTime)
-					s.Unlock()
-					continue
-				}
-				if iti == nil {
-					s.Lock()
-					s.timer.Reset(maxWaitTime)
-					s.Unlock()
-					continue
-				}
-				it := iti.(item)
-				if prom, err := s.executor(context.Background(), it.id, time.Unix(it.next, 0)); err == nil {
-					t, err := it.cron.Next(s.time.Unix(it.next, 0))
-					it.next = t.Unix()
-					// we need to return the item to the scheduled before calling s.onErr
-					if err != nil {
-						it.nonce++
-						s.onErr(context.TODO(), it.id, prom.ID(), time.Unix(it.next, 0), err)
-					}
-					s.scheduled.ReplaceOrInsert(it)
-					if prom == nil {
-						break
-					}
-					s.Lock()
-					s.running.ReplaceOrInsert(runningItem{cancel: prom.Cancel, runID: prom.ID(), taskID: ID(it.id)})
-					s.Unlock()
-
-					s.wg.Add(1)
-
-					s.sema <- struct{}{}
-					go func(it item) {
-						defer func() {
-							s.wg.Done()
-							<-s.sema
-						}()
-						<-prom.Done()
-						err := prom.Error()
-						if err != nil {
-							s.onErr(context.TODO(), it.id, prom.ID(), time.Unix(it.next, 0), err)
-							return
-						}
-						s.Lock()
-						s.running.Delete(runningItem{cancel: prom.Cancel, runID: ID(prom.ID()), taskID: ID(it.id)})
-						s.Unlock()
-
-						// TODO(docmerlin); handle statistics on the run
-					}(it)
-				} else if err != nil {
-					s.onErr(context.Background(), it.id, 0, time.Unix(it.next, 0), err)
-				}
-			}
+		defer func() {
+			s.wg.Done()
+			<-s.sema
+		}()
+		<-prom.Done()
+		runErr := prom.Error()
+		finishedAt := s.time.Now()
+
+		sh := s.runningShardFor(it.id)
+		sh.mu.Lock()
+		sh.tree.Delete(runningItem{runID: prom.ID(), taskID: it.id})
+		_ = s.store.CompleteRun(context.Background(), it.id, prom.ID(), runErr)
+		sh.mu.Unlock()
+		s.metrics.SetRunningGauge(s.runningLen())
+
+		s.metrics.ObserveRunDuration(it.id, finishedAt.Sub(startedAt))
+		s.metrics.IncRunResult(it.id, errorClass(runErr))
+
+		if s.runHistory != nil {
+			s.runHistory.RecordRun(RunRecord{
+				TaskID:      it.id,
+				RunID:       prom.ID(),
+				ScheduledAt: scheduledAt,
+				StartedAt:   startedAt,
+				FinishedAt:  finishedAt,
+				Err:         runErr,
+			})
 		}
+
+		if runErr == nil {
+			return
+		}
+		decision := s.onErr(context.TODO(), it.id, prom.ID(), scheduledAt, runErr)
+		s.recordFailure(it, finishedAt, runErr, decision)
 	}()
-	return s, nil
+	return prom, nil
+}
+
+// scheduleRetry re-inserts it at PriorityRetry, replacing whatever entry
+// id currently holds. The delay before it fires again is
+// max(cron.Next(it.next), now+s.backoff(it.nonce, cron.Next(it.next))): the
+// backoff can push a badly-behaving task's retry later than its own cron
+// cadence would, but never sooner, so a flapping task can't end up firing
+// more often than its schedule intends. Keeping retries in their own
+// bucket, above Normal but below TryJob/Force, means a single flapping
+// task gets another attempt promptly without starving fresh work.
+func (s *TreeScheduler) scheduleRetry(it item) {
+	it.nonce++
+	it.prio = PriorityRetry
+
+	now := s.time.Now()
+	cronNext := now
+	if it.hasCron() {
+		if t, err := it.cron.Next(s.time.Unix(it.next, 0)); err == nil {
+			cronNext = t
+		}
+	}
+	next := cronNext
+	if backoffUntil := now.Add(s.backoff(it.nonce, cronNext)); backoffUntil.After(next) {
+		next = backoffUntil
+	}
+	it.next = next.Unix()
+
+	s.Lock()
+	s.deleteScheduledLocked(it.id)
+	s.insertScheduledLocked(it)
+	s.bumpWakeLocked(next)
+	_ = s.store.UpsertScheduled(context.Background(), it.stored())
+	s.Unlock()
 }
 
 func (s *TreeScheduler) Stop() {
@@ -213,24 +413,25 @@ func (s *TreeScheduler) When() time.Time {
 // Task deletion would be faster if the tree supported deleting ranges.
 func (s *TreeScheduler) Release(taskID ID) error {
 	s.Lock()
-	defer s.Unlock()
-	nextTime, ok := s.nextTime[taskID]
-	if !ok {
-		return nil
-	}
-
-	// delete the old task run time
-	s.scheduled.Delete(item{
-		next: nextTime,
-		id:   taskID,
-	})
-
-	s.running.AscendGreaterOrEqual(runningItem{taskID: taskID}, s.clearTask(taskID))
-	return nil
+	s.deleteScheduledLocked(taskID)
+	s.clearQuarantineLocked(taskID)
+	err := s.store.DeleteScheduled(context.Background(), taskID)
+	s.Unlock()
+	s.runningClearTask(taskID)
+	return err
 }
 
-// put puts an Item on the TreeScheduler.
+// Schedule puts a task on the TreeScheduler at the default, Normal priority.
 func (s *TreeScheduler) Schedule(id ID, cronString string, offset time.Duration, since time.Time) error {
+	return s.ScheduleWithPriority(id, cronString, offset, since, PriorityNormal)
+}
+
+// ScheduleWithPriority puts a task on the TreeScheduler in the bucket for
+// prio. Higher priority buckets are always drained before lower ones when
+// the scheduler wakes up and finds more than one item due, so a task
+// scheduled at, say, PriorityTryJob runs ahead of ordinary PriorityNormal
+// traffic that's also due.
+func (s *TreeScheduler) ScheduleWithPriority(id ID, cronString string, offset time.Duration, since time.Time, prio Priority) error {
 	crSch, err := cron.ParseUTC(cronString)
 	if err != nil {
 		return err
@@ -240,56 +441,97 @@ func (s *TreeScheduler) Schedule(id ID, cronString string, offset time.Duration,
 		return err
 	}
 	it := item{
-		cron: crSch,
-		next: nt.Add(offset).Unix(),
-		id:   id,
+		cron:       crSch,
+		cronString: cronString,
+		next:       nt.Add(offset).Unix(),
+		offset:     int(offset),
+		id:         id,
+		prio:       prio,
 	}
+
 	s.Lock()
 	defer s.Unlock()
-	nextTime, ok := s.nextTime[id]
-	if !ok {
-		s.scheduled.ReplaceOrInsert(it)
-		return nil
+	s.deleteScheduledLocked(id)
+	s.clearQuarantineLocked(id)
+	s.insertScheduledLocked(it)
+	s.bumpWakeLocked(nt.Add(offset))
+	return s.store.UpsertScheduled(context.Background(), it.stored())
+}
+
+// ForceRun executes id immediately via the executor, ignoring its cron
+// schedule entirely, and returns the resulting Promise. Any pending entry
+// for id is removed first so the task doesn't also fire on its own the next
+// time the scheduler wakes; if id had a cron schedule its next ordinary
+// occurrence is requeued at PriorityNormal once the forced run has started,
+// the same way a regular tick requeues itself.
+func (s *TreeScheduler) ForceRun(id ID) (Promise, error) {
+	s.Lock()
+	old, had := s.deleteScheduledLocked(id)
+	s.Unlock()
+
+	it := old
+	it.id = id
+	it.prio = PriorityForce
+	it.next = s.time.Now().Unix()
+
+	prom, err := s.runItem(context.Background(), it)
+	if err != nil {
+		return nil, err
 	}
 
-	if s.when.Before(nt) {
-		s.when = nt
-		if !s.timer.Stop() {
-			<-s.timer.C()
+	if had {
+		if t, cerr := it.cron.Next(s.time.Unix(it.next, 0)); cerr == nil {
+			next := it
+			next.next = t.Unix()
+			next.prio = PriorityNormal
+			s.Lock()
+			s.insertScheduledLocked(next)
+			s.bumpWakeLocked(t)
+			_ = s.store.UpsertScheduled(context.Background(), next.stored())
+			s.Unlock()
 		}
-		s.timer.Reset(time.Until(s.when))
 	}
-
-	// delete the old task run time
-	s.scheduled.Delete(item{
-		next: nextTime,
-		id:   id,
-	})
-
-	// insert the new task run time
-	s.scheduled.ReplaceOrInsert(it)
-	return nil
+	return prom, nil
 }
 
 func (s *TreeScheduler) Runs(taskID ID, limit int) []ID {
-	s.RLock()
-	defer s.RUnlock()
-	iter, acc := s.runs(taskID, limit)
-	s.running.AscendGreaterOrEqual(runningItem{taskID: 0}, iter)
-	return acc
+	return s.runningIDs(taskID, limit)
 }
 
 // Item is a task in the scheduler.
 type item struct {
-	cron   cron.Parsed
-	next   int64
-	nonce  int // for retries
-	offset int
-	id     ID
+	cron       cron.Parsed
+	cronString string
+	next       int64
+	nonce      int // for retries
+	offset     int
+	id         ID
+	prio       Priority
+}
+
+// hasCron reports whether it carries a cron schedule parsed by
+// cron.ParseUTC. cron.Parsed's zero value isn't a valid "no schedule"
+// sentinel as far as the cron package is concerned: calling Next on it
+// indexes out of range instead of returning an error, so callers must check
+// hasCron before calling it.cron.Next.
+func (it item) hasCron() bool {
+	return it.cron != cron.Parsed{}
+}
+
+// stored converts it into the form persisted to the SchedulerStore.
+func (it item) stored() StoredItem {
+	return StoredItem{
+		TaskID:     it.id,
+		CronString: it.cronString,
+		Offset:     time.Duration(it.offset),
+		Next:       time.Unix(it.next, 0),
+		Nonce:      it.nonce,
+		Priority:   it.prio,
+	}
 }
 
 // Less tells us if one Item is less than another
 func (it item) Less(bItem btree.Item) bool {
 	it2 := bItem.(item)
 	return it.next < it2.next || (it.next == it2.next && (it.nonce < it2.nonce || it.nonce == it2.nonce && it.id < it2.id))
-}
\ No newline at end of file
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestScheduler returns a TreeScheduler built through NewScheduler, so it
+// exercises the same construction path (btree init, timer, worker pool) as
+// production, with an executor that's never actually invoked by the tests in
+// this file: they drive popDueLocked/insertScheduledLocked directly instead
+// of waiting on the dispatch timer.
+func newTestScheduler(t *testing.T, opts ...treeSchedulerOptFunc) *TreeScheduler {
+	t.Helper()
+	s, err := NewScheduler(func(_ context.Context, id ID, _ time.Time) (Promise, error) {
+		return newFakePromise(id), nil
+	}, append([]treeSchedulerOptFunc{WithTime(stdTime{})}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestPopDueLockedDrainsHighestPriorityFirst(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Unix(1000, 0)
+
+	// Inserted out of priority order, so a naive FIFO drain would get this
+	// wrong; popDueLocked must still come back Force, TryJob, Retry, Normal.
+	s.Lock()
+	s.insertScheduledLocked(item{id: ID(1), next: now.Unix(), prio: PriorityNormal})
+	s.insertScheduledLocked(item{id: ID(2), next: now.Unix(), prio: PriorityRetry})
+	s.insertScheduledLocked(item{id: ID(3), next: now.Unix(), prio: PriorityForce})
+	s.insertScheduledLocked(item{id: ID(4), next: now.Unix(), prio: PriorityTryJob})
+
+	want := []ID{3, 4, 2, 1}
+	for _, id := range want {
+		it, ok := s.popDueLocked(now)
+		if !ok {
+			t.Fatalf("popDueLocked: expected an item for id %d, got none", id)
+		}
+		if it.id != id {
+			t.Fatalf("popDueLocked: got id %d, want %d", it.id, id)
+		}
+	}
+	if _, ok := s.popDueLocked(now); ok {
+		t.Fatal("popDueLocked: expected buckets to be empty")
+	}
+	s.Unlock()
+}
+
+func TestPopDueLockedSkipsNotYetDue(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Unix(1000, 0)
+	future := now.Add(time.Hour)
+
+	s.Lock()
+	s.insertScheduledLocked(item{id: ID(1), next: future.Unix(), prio: PriorityNormal})
+	if _, ok := s.popDueLocked(now); ok {
+		t.Fatal("popDueLocked: item scheduled an hour out should not be due yet")
+	}
+	it, ok := s.popDueLocked(future)
+	if !ok || it.id != 1 {
+		t.Fatalf("popDueLocked(future) = %+v, %v; want id 1, true", it, ok)
+	}
+	s.Unlock()
+}
+
+func TestPopDueLockedOrdersWithinABucketByNext(t *testing.T) {
+	s := newTestScheduler(t)
+	now := time.Unix(1000, 0)
+
+	s.Lock()
+	s.insertScheduledLocked(item{id: ID(1), next: now.Add(2 * time.Second).Unix(), prio: PriorityNormal})
+	s.insertScheduledLocked(item{id: ID(2), next: now.Unix(), prio: PriorityNormal})
+
+	it, ok := s.popDueLocked(now.Add(2 * time.Second))
+	if !ok || it.id != 2 {
+		t.Fatalf("popDueLocked: got %+v, %v; want the earlier-next item (id 2) first", it, ok)
+	}
+	s.Unlock()
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultWorkers matches the prior behavior of a single goroutine doing
+// both dispatch and executor kick-off.
+const defaultWorkers = 1
+
+// WithWorkers sets the number of worker goroutines that invoke the
+// executor for items the dispatcher has popped. Raising n lets executor
+// kick-off for unrelated tasks proceed concurrently instead of serializing
+// behind a single dispatch goroutine. The default is 1.
+func WithWorkers(n int) treeSchedulerOptFunc {
+	return func(t *TreeScheduler) error {
+		if n < 1 {
+			return errors.New("WithWorkers: n must be >= 1")
+		}
+		t.numWorkers = n
+		return nil
+	}
+}
+
+// dispatchLoop owns the wake timer and the priority buckets: it is the only
+// goroutine that pops scheduled items. On every wake it drains every item
+// that's due in one pass under a single lock acquisition, hands each to the
+// worker pool over jobs, and only then recomputes the next wake time. This
+// matters once more than one item is due at the same instant: popping and
+// handling them one at a time would reset the timer to fire almost
+// immediately after each pop, thrashing through the select loop once per
+// item instead of once per batch.
+func (s *TreeScheduler) dispatchLoop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			s.shutdown()
+			return
+		case <-s.timer.C:
+			s.Lock()
+			now := s.time.Now()
+			s.healQuarantinedLocked(now)
+			var due []item
+			for {
+				it, ok := s.popDueLocked(now)
+				if !ok {
+					break
+				}
+				due = append(due, it)
+			}
+			s.Unlock()
+
+			for _, it := range due {
+				s.requeueNext(it)
+				// jobs is only buffered to numWorkers, so this blocks once
+				// every worker is busy. Guard it against s.done too, or
+				// Stop() hangs forever waiting for a dispatcher that's
+				// stuck feeding a full jobs channel nobody's draining.
+				select {
+				case s.jobs <- it:
+				case <-s.done:
+					s.shutdown()
+					return
+				}
+			}
+
+			s.Lock()
+			s.resetWakeLocked()
+			s.Unlock()
+		}
+	}
+}
+
+// shutdown stops the wake timer and closes jobs and sema, so the worker
+// pool's range over jobs and Stop's semaphore drain both terminate.
+func (s *TreeScheduler) shutdown() {
+	s.Lock()
+	s.timer.Stop()
+	s.Unlock()
+	close(s.jobs)
+	close(s.sema)
+}
+
+// requeueNext puts its next ordinary cron occurrence back in the
+// PriorityNormal bucket before the current one runs, so a slow or failing
+// run doesn't delay the task's regular cadence.
+func (s *TreeScheduler) requeueNext(it item) {
+	if !it.hasCron() {
+		s.onErr(context.TODO(), it.id, 0, time.Unix(it.next, 0), errors.New("requeueNext: item has no parsed cron schedule"))
+		return
+	}
+	t, err := it.cron.Next(s.time.Unix(it.next, 0))
+	if err != nil {
+		s.onErr(context.TODO(), it.id, 0, time.Unix(it.next, 0), err)
+		return
+	}
+	next := it
+	next.next = t.Unix()
+	next.prio = PriorityNormal
+	s.Lock()
+	s.insertScheduledLocked(next)
+	_ = s.store.UpsertScheduled(context.Background(), next.stored())
+	s.Unlock()
+}
+
+// workerLoop takes due items off jobs and runs them through the executor
+// until jobs is closed by dispatchLoop at shutdown. An error here means the
+// executor itself failed to start the run (as opposed to the run's Promise
+// failing later, handled inside runItem), so it goes through the same
+// onErr/recordFailure path a run failure would, rather than being dropped:
+// a persistently erroring executor needs to be retried with backoff or
+// quarantined just as much as a persistently failing run does.
+func (s *TreeScheduler) workerLoop() {
+	defer s.wg.Done()
+	for it := range s.jobs {
+		if _, err := s.runItem(context.Background(), it); err != nil {
+			scheduledAt := time.Unix(it.next, 0)
+			decision := s.onErr(context.Background(), it.id, 0, scheduledAt, err)
+			s.recordFailure(it, s.time.Now(), err, decision)
+		}
+	}
+}